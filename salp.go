@@ -1,7 +1,14 @@
+//go:build !salp_purego
+
 // Package salp enables the definition and firing of USDT probes at runtime by
 // Go programs running on Linux. These probes impose little or no overhead when
 // not in use and are available for use by any tool that is able to monitor USDT
 // probe points (e.g. the trace tool from the bcc project).
+//
+// This file holds the default backend, which links against libstapsdt via
+// CGO. Build with the salp_purego tag to select the pure-Go backend in
+// salp_purego.go instead, e.g. for static binaries or environments where
+// libstapsdt.so isn't installed.
 package salp
 
 /*
@@ -71,6 +78,8 @@ void salp_probeFire(SDTProbe_t* p, void** args) {
 import "C"
 import (
 	"fmt"
+	"math"
+	"sync"
 	"unsafe"
 )
 
@@ -79,6 +88,10 @@ type stapsdtError struct {
 	msg  string
 }
 
+// maxArguments mirrors libstapsdt's MAX_ARGUMENTS, checked against the real
+// value above at compile time.
+const maxArguments = 6
+
 // Provider represents a named collection of probes
 type Provider = C.struct_SDTProvider
 
@@ -129,8 +142,103 @@ const (
 
 	// Probe argument should be treated as a Go error
 	Error = String
+
+	// Probe argument should be treated as a float32, encoded via
+	// math.Float32bits into the uint32 slot libstapsdt sees
+	Float32 = Uint32
+
+	// Probe argument should be treated as a float64, encoded via
+	// math.Float64bits into the uint64 slot libstapsdt sees
+	Float64 = Uint64
+
+	// Probe argument should be treated as an unsafe.Pointer or uintptr
+	Ptr = Uint64
+
+	// Probe argument should be treated as a []byte. Unlike the other
+	// ProbeArgTypes, Bytes expands to two underlying libstapsdt argument
+	// slots (a pointer and a length) and so counts as two arguments against
+	// the six-argument limit enforced by AddProbe. Bytes itself is never
+	// passed to libstapsdt; it is expanded to a (Ptr, Uint64) pair first, so
+	// its value need only be distinct from the real ArgType_t values above.
+	Bytes = ProbeArgType(Int64 + 1)
+)
+
+// String returns a human-readable name for t, as used in the schemas
+// Registry.Snapshot and GET /probes report. Because libstapsdt has no
+// ArgType_t of its own for String, Error, Float64, or Ptr, this backend
+// cannot tell them apart from a plain Uint64 once a probe is registered;
+// all four report as "uint64".
+func (t ProbeArgType) String() string {
+	switch t {
+	case Uint8:
+		return "uint8"
+	case Int8:
+		return "int8"
+	case Uint16:
+		return "uint16"
+	case Int16:
+		return "int16"
+	case Uint32:
+		return "uint32"
+	case Int32:
+		return "int32"
+	case Int64:
+		return "int64"
+	case Bytes:
+		return "bytes"
+	case Uint64:
+		return "uint64"
+	default:
+		return fmt.Sprintf("ProbeArgType(%d)", t)
+	}
+}
+
+// isFloat32ArgType reports whether t is specifically the Float32 argument
+// type, as opposed to Uint32. This backend always answers false: Float32
+// aliases Uint32 here (see the Float32 const doc), so there is no way to
+// tell the two apart once a ProbeArgType value is in hand.
+func isFloat32ArgType(t ProbeArgType) bool {
+	return false
+}
+
+// expandArgTypes translates the ProbeArgType list passed to AddProbe into the
+// flat list of ArgType_t slots actually registered with libstapsdt: every
+// type maps to itself except Bytes, which expands to a (Ptr, Uint64) pair.
+func expandArgTypes(argTypes []ProbeArgType) []ProbeArgType {
+	slots := make([]ProbeArgType, 0, len(argTypes))
+	for _, t := range argTypes {
+		if t == Bytes {
+			slots = append(slots, Ptr, Uint64)
+			continue
+		}
+		slots = append(slots, t)
+	}
+	return slots
+}
+
+// probeArgCounts records, for each live Probe, the number of logical
+// arguments (as opposed to underlying libstapsdt slots) it was declared with,
+// so that Fire can validate arity even when Bytes arguments are in play.
+// Entries persist for the life of the process; providers are typically
+// created once at startup and live until the process exits.
+var (
+	probeArgCountsMu sync.Mutex
+	probeArgCounts   = map[*Probe]int{}
 )
 
+func setProbeArgCount(p *Probe, n int) {
+	probeArgCountsMu.Lock()
+	probeArgCounts[p] = n
+	probeArgCountsMu.Unlock()
+}
+
+func probeArgCount(p *Probe) int {
+	probeArgCountsMu.Lock()
+	n := probeArgCounts[p]
+	probeArgCountsMu.Unlock()
+	return n
+}
+
 // Error returns a string describing the error condition. The string will
 // include an error code and a message.
 func (e stapsdtError) Error() string {
@@ -176,44 +284,33 @@ func (p *Provider) Load() error {
 
 // AddProbe creates a new Probe instance with the supplied name and assiciates
 // it with this Provider. The argTypes describe the arguments that are expected
-// to be supplied when Fire is called on this Probe.
+// to be supplied when Fire is called on this Probe. Note that a Bytes argType
+// occupies two of the six argument slots libstapsdt allows.
 func (p *Provider) AddProbe(name string, argTypes ...ProbeArgType) (*Probe, error) {
+	slots := expandArgTypes(argTypes)
+	if l := len(slots); l > maxArguments {
+		return nil, stapsdtError{msg: fmt.Sprintf(
+			"probe %q: %d argument slots exceeds the %d argument limit",
+			name, l, maxArguments)}
+	}
+
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
 
 	var pp *Probe
-	if l := len(argTypes); l == 0 {
+	if l := len(slots); l == 0 {
 		pp = C.salp_providerAddProbe(p, cname, 0, nil)
 	} else {
 		pp = C.salp_providerAddProbe(
-			p, cname, C.uint32_t(l), (*C.ArgType_t)(&argTypes[0]))
+			p, cname, C.uint32_t(l), (*C.ArgType_t)(&slots[0]))
 	}
 	if pp == nil {
 		return nil, p.err()
 	}
+	setProbeArgCount(pp, len(argTypes))
 	return pp, nil
 }
 
-// MustAddProbe is a helper function that either adds a probe with the supplied
-// name and argument types to the specified provider or, in the case of an
-// error, panics.
-func MustAddProbe(p *Provider, name string, argTypes ...ProbeArgType) *Probe {
-	prb, err := p.AddProbe(name, argTypes...)
-	if err != nil {
-		panic(err)
-	}
-	return prb
-}
-
-// MustLoadProvider is a helper function the either calls Load() on the supplied
-// Provider instance or in the case of an error, panics
-func MustLoadProvider(p *Provider) {
-	err := p.Load()
-	if err != nil {
-		panic(err)
-	}
-}
-
 // Unload transitions this Provider from the loaded to the unloaded state.
 // Associated probes are detached and must be re-attached in order to function.
 func (p *Provider) Unload() {
@@ -242,10 +339,12 @@ func (p *Probe) Enabled() bool {
 
 // Fire invokes the Probe with the provided arguments. The type and arity of
 // this invocation should match what was described by the ProbeArgType arguments
-// originally given to the Provider.AddProbe invocation that created this Probe.
-// Cheap to invoke if the probe is not enabled (see: Enabled())
+// originally given to the Provider.AddProbe invocation that created this Probe
+// (a Bytes argument is still just one argument to Fire, even though it
+// occupies two underlying slots). Cheap to invoke if the probe is not enabled
+// (see: Enabled())
 func (p *Probe) Fire(args ...interface{}) {
-	if !p.Enabled() || len(args) != int(p.argCount) {
+	if !p.Enabled() || len(args) != probeArgCount(p) {
 		return
 	}
 
@@ -260,47 +359,66 @@ func (p *Probe) Fire(args ...interface{}) {
 
 func (p *Probe) fireImpl(args ...interface{}) {
 	ba := [6]unsafe.Pointer{}
-	for i := 0; i < len(args); i++ {
+	slot := 0
+	for i := 0; i < len(args) && slot < len(ba); i++ {
 		switch ta := args[i].(type) {
 		case bool:
 			var arg uint8
 			if ta {
 				arg = 1
 			}
-			ba[i] = unsafe.Pointer(uintptr(arg))
+			ba[slot] = unsafe.Pointer(uintptr(arg))
 		case int8:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case uint8: // catches byte
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case int16:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case uint16:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case int:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case uint:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case int32:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case uint32:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case int64:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
 		case uint64:
-			ba[i] = unsafe.Pointer(uintptr(ta))
+			ba[slot] = unsafe.Pointer(uintptr(ta))
+		case float32:
+			ba[slot] = unsafe.Pointer(uintptr(math.Float32bits(ta)))
+		case float64:
+			ba[slot] = unsafe.Pointer(uintptr(math.Float64bits(ta)))
 		case uintptr:
-			ba[i] = unsafe.Pointer(ta)
+			ba[slot] = unsafe.Pointer(ta)
+		case unsafe.Pointer:
+			ba[slot] = ta
 		case string:
 			strptr := unsafe.Pointer(C.CString(ta))
 			defer C.free(strptr)
-			ba[i] = strptr
+			ba[slot] = strptr
 		case error:
 			cstr := unsafe.Pointer(C.CString(ta.Error()))
 			defer C.free(cstr)
-			ba[i] = cstr
+			ba[slot] = cstr
+		case []byte:
+			var bptr unsafe.Pointer
+			if len(ta) > 0 {
+				bptr = unsafe.Pointer(&ta[0])
+			}
+			ba[slot] = bptr
+			slot++
+			if slot >= len(ba) {
+				return
+			}
+			ba[slot] = unsafe.Pointer(uintptr(len(ta)))
 		default:
 			return
 		}
+		slot++
 	}
 	C.salp_probeFire(p, &ba[0])
 }
@@ -310,9 +428,133 @@ func (p *Probe) Name() string {
 	return C.GoString(p.name)
 }
 
-// UnloadAndDispose is a convenience function suitable for deferred invocation
-// that calls p.Unload() and then p.Dispose().
-func UnloadAndDispose(p *Provider) {
-	p.Unload()
-	p.Dispose()
+// argsPools holds one sync.Pool of *Args per possible probe arity (0 through
+// maxArguments), so FireFunc can reuse a scratch buffer sized for the probe
+// it's firing instead of allocating one per call.
+var argsPools [maxArguments + 1]sync.Pool
+
+func getArgs(arity int) *Args {
+	a, _ := argsPools[arity].Get().(*Args)
+	if a == nil {
+		a = &Args{arity: arity}
+	}
+	a.slot = 0
+	return a
+}
+
+func putArgs(a *Args) {
+	argsPools[a.arity].Put(a)
+}
+
+// Args is the scratch area Probe.FireFunc hands to its build function. Its
+// typed setters are called in argument order and write straight into a
+// buffer reused across calls (see argsPools), rather than allocating a new C
+// string via C.CString for every string or error argument the way Fire does.
+type Args struct {
+	arity int
+	slot  int
+	ba    [maxArguments]unsafe.Pointer
+	cbufs [maxArguments][]byte // reusable NUL-terminated scratch, one per slot
+}
+
+// room reports whether n more slots can still be written, silently
+// protecting against a FireFunc closure that calls more setters than the
+// probe has argument slots for -- the same "ignore a mismatched call instead
+// of crashing" behavior Fire falls back to for a bad arg count, which
+// matters here because Args is only ever touched once a tracer is attached.
+func (a *Args) room(n int) bool {
+	return a.slot+n <= a.arity
+}
+
+// SetInt64 sets the next argument slot to v. Use it for any of the integer
+// ProbeArgTypes; libstapsdt only distinguishes them by width at note-creation
+// time, not at Fire time. A call beyond the probe's declared arity is
+// ignored.
+func (a *Args) SetInt64(v int64) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = unsafe.Pointer(uintptr(v))
+	a.slot++
+}
+
+// SetFloat64 sets the next argument slot to v, encoded via math.Float64bits
+// the same way Fire encodes a float64 argument. A call beyond the probe's
+// declared arity is ignored.
+func (a *Args) SetFloat64(v float64) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = unsafe.Pointer(uintptr(math.Float64bits(v)))
+	a.slot++
+}
+
+// SetString sets the next argument slot to v, copying it into a reusable
+// NUL-terminated buffer instead of malloc'ing a new C string. A call beyond
+// the probe's declared arity is ignored.
+func (a *Args) SetString(v string) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = a.cstr(v)
+	a.slot++
+}
+
+// SetError sets the next argument slot to err.Error(), using the same
+// reusable buffer as SetString. A call beyond the probe's declared arity is
+// ignored.
+func (a *Args) SetError(err error) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = a.cstr(err.Error())
+	a.slot++
+}
+
+// SetBytes sets the next two argument slots to a (pointer, length) pair
+// describing b, matching the Bytes ProbeArgType. A call that would overflow
+// the probe's declared arity is ignored.
+func (a *Args) SetBytes(b []byte) {
+	if !a.room(2) {
+		return
+	}
+	var ptr unsafe.Pointer
+	if len(b) > 0 {
+		ptr = unsafe.Pointer(&b[0])
+	}
+	a.ba[a.slot] = ptr
+	a.slot++
+	a.ba[a.slot] = unsafe.Pointer(uintptr(len(b)))
+	a.slot++
+}
+
+// cstr copies s into this slot's reusable buffer, NUL-terminated, growing the
+// buffer if needed, and returns a pointer to it.
+func (a *Args) cstr(s string) unsafe.Pointer {
+	buf := a.cbufs[a.slot]
+	need := len(s) + 1
+	if cap(buf) < need {
+		buf = make([]byte, need)
+	} else {
+		buf = buf[:need]
+	}
+	copy(buf, s)
+	buf[len(s)] = 0
+	a.cbufs[a.slot] = buf
+	return unsafe.Pointer(&buf[0])
+}
+
+// FireFunc is like Fire, but build is only invoked when Enabled() returns
+// true, and the *Args it's given encodes arguments into a buffer reused
+// across calls instead of allocating new C strings for every string or error
+// argument. Prefer this over Fire on hot paths whose arguments are expensive
+// to compute or include strings.
+func (p *Probe) FireFunc(build func(*Args)) {
+	if !p.Enabled() {
+		return
+	}
+	a := getArgs(int(p.argCount))
+	defer putArgs(a)
+	build(a)
+	C.salp_probeFire(p, &a.ba[0])
 }
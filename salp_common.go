@@ -0,0 +1,28 @@
+package salp
+
+// MustAddProbe is a helper function that either adds a probe with the supplied
+// name and argument types to the specified provider or, in the case of an
+// error, panics.
+func MustAddProbe(p *Provider, name string, argTypes ...ProbeArgType) *Probe {
+	prb, err := p.AddProbe(name, argTypes...)
+	if err != nil {
+		panic(err)
+	}
+	return prb
+}
+
+// MustLoadProvider is a helper function the either calls Load() on the supplied
+// Provider instance or in the case of an error, panics
+func MustLoadProvider(p *Provider) {
+	err := p.Load()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// UnloadAndDispose is a convenience function suitable for deferred invocation
+// that calls p.Unload() and then p.Dispose().
+func UnloadAndDispose(p *Provider) {
+	p.Unload()
+	p.Dispose()
+}
@@ -0,0 +1,43 @@
+//go:build !salp_purego
+
+package salp
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// forceEnabled overwrites a probe's landing-pad byte as if a tracer had
+// attached to it, so that Enabled()-gated code (FireFunc, Fire) can be
+// benchmarked/tested on its "enabled" path without a real tracer. The pad
+// lives in libstapsdt's read+exec mapping, so write access is granted first
+// via mprotect, and the byte written is a bare REX prefix (0x40) rather than
+// a breakpoint, so that executing the pad still just falls through to RET.
+func forceEnabled(p *Probe) {
+	const pageSize = 4096
+	page := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(p._fire)&^(pageSize-1))), pageSize)
+	if err := syscall.Mprotect(page, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC); err != nil {
+		panic(err)
+	}
+	*(*uint8)(p._fire) = 0x40
+}
+
+// BenchmarkFireEnabledStrings measures FireFunc's lazy-encoding path with
+// string arguments against a probe forced into the "enabled" state, so the
+// cost of Args' reusable scratch buffer (instead of C.CString/C.free) is
+// actually exercised rather than short-circuited by Enabled() returning
+// false.
+func BenchmarkFireEnabledStrings(b *testing.B) {
+	pv := NewProvider("foo")
+	pr := MustAddProbe(pv, "bar", String, String)
+	MustLoadProvider(pv)
+	forceEnabled(pr)
+
+	for i := 0; i < b.N; i++ {
+		pr.FireFunc(func(a *Args) {
+			a.SetString("hello")
+			a.SetString("world")
+		})
+	}
+}
@@ -0,0 +1,772 @@
+//go:build salp_purego && linux && amd64
+
+// This file provides the pure-Go backend for Provider/Probe selected by the
+// salp_purego build tag. It exists so that programs can avoid the CGO
+// dependency on libstapsdt entirely -- useful for static binaries and for
+// scratch containers where libstapsdt.so is not installed.
+//
+// Rather than calling into libstapsdt, this backend builds the same artifact
+// libstapsdt builds for itself: a tiny ELF object carrying a .note.stapsdt
+// note per probe (describing provider/probe/argument-spec strings) and a
+// .stapsdt.base anchor section that external tools (bcc, bpftrace) use to
+// translate the note's recorded addresses into runtime addresses. The object
+// is realized with memfd_create+mmap so it shows up like any other mapped
+// shared object in /proc/<pid>/maps, and each probe gets its own landing pad:
+// a single NOP byte that Fire reaches via a small asm trampoline
+// (callTrampoline, in salp_purego_amd64.s) that places arguments into the
+// System V AMD64 registers the recorded argument spec promises the tracer
+// (e.g. "-4@%rdi").
+package salp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// ProbeArgType specifies the type of each individual parameter than can be
+// specified when firing a Probe.
+type ProbeArgType uint8
+
+// ProbeArgTypes are used to specify the type of parameters accepted when firing
+// a Probe
+const (
+	// Probe argument should be treated as a uint8
+	Uint8 = ProbeArgType(iota + 1)
+
+	// Probe argument should be treated as an int8
+	Int8
+
+	// Probe argument should be treated as a uint16
+	Uint16
+
+	// Probe argument should be treated as an int16
+	Int16
+
+	// Probe argument should be treated as a uint32
+	Uint32
+
+	// Probe argument should be treated as an int32
+	Int32
+
+	// Probe argument should be treated as a uint64
+	Uint64
+
+	// Probe argument should be treated as an int64
+	Int64
+
+	// Probe argument should be treated as a string
+	String
+
+	// Probe argument should be treated as a float32, encoded via
+	// math.Float32bits into the 4-byte slot the note describes
+	Float32
+
+	// Probe argument should be treated as a float64, encoded via
+	// math.Float64bits into the 8-byte slot the note describes
+	Float64
+
+	// Probe argument should be treated as an unsafe.Pointer or uintptr
+	Ptr
+
+	// Probe argument should be treated as a []byte. Unlike the other
+	// ProbeArgTypes, Bytes expands to two underlying argument slots (a
+	// pointer and a length) and so counts as two arguments against the
+	// six-argument limit enforced by AddProbe.
+	Bytes
+
+	// Probe argument should be treated as a bool
+	Bool = Uint8
+
+	// Probe argument should be treated as a byte
+	Byte = Uint8
+
+	// Probe argument should be treated as a Go error
+	Error = String
+)
+
+// maxArguments mirrors libstapsdt's MAX_ARGUMENTS.
+const maxArguments = 6
+
+// String returns a human-readable name for t, as used in the schemas
+// Registry.Snapshot and GET /probes report.
+func (t ProbeArgType) String() string {
+	switch t {
+	case Uint8:
+		return "uint8"
+	case Int8:
+		return "int8"
+	case Uint16:
+		return "uint16"
+	case Int16:
+		return "int16"
+	case Uint32:
+		return "uint32"
+	case Int32:
+		return "int32"
+	case Uint64:
+		return "uint64"
+	case Int64:
+		return "int64"
+	case String:
+		return "string"
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	case Ptr:
+		return "ptr"
+	case Bytes:
+		return "bytes"
+	default:
+		return fmt.Sprintf("ProbeArgType(%d)", t)
+	}
+}
+
+// isFloat32ArgType reports whether t is specifically the Float32 argument
+// type, which this backend can tell apart from Uint32 since they're distinct
+// values (see the Float32 const).
+func isFloat32ArgType(t ProbeArgType) bool {
+	return t == Float32
+}
+
+// argSpec describes how a ProbeArgType is rendered into the argument-type
+// spec string embedded in the note (e.g. "-4@%rdi"), whose numeric prefix
+// gives the value's width in bytes (negative for signed types).
+func (t ProbeArgType) argSpec(reg string) (string, error) {
+	switch t {
+	case Uint8:
+		return fmt.Sprintf("1@%s", reg), nil
+	case Int8:
+		return fmt.Sprintf("-1@%s", reg), nil
+	case Uint16:
+		return fmt.Sprintf("2@%s", reg), nil
+	case Int16:
+		return fmt.Sprintf("-2@%s", reg), nil
+	case Uint32:
+		return fmt.Sprintf("4@%s", reg), nil
+	case Int32:
+		return fmt.Sprintf("-4@%s", reg), nil
+	case Uint64, String, Float64, Ptr:
+		return fmt.Sprintf("8@%s", reg), nil
+	case Int64:
+		return fmt.Sprintf("-8@%s", reg), nil
+	case Float32:
+		return fmt.Sprintf("4@%s", reg), nil
+	default:
+		return "", fmt.Errorf("salp: unknown ProbeArgType %d", t)
+	}
+}
+
+// sysvArgRegs lists the System V AMD64 integer argument registers in order,
+// which is also the register order libstapsdt's argument spec strings assume.
+var sysvArgRegs = [maxArguments]string{"%rdi", "%rsi", "%rdx", "%rcx", "%r8", "%r9"}
+
+type stapsdtError struct {
+	msg string
+}
+
+func (e stapsdtError) Error() string {
+	return fmt.Sprintf("salp (purego): %v", e.msg)
+}
+
+// probeSite is the runtime landing pad for a single probe: a 1-byte NOP
+// inside the provider's mapped image that tracers patch in order to observe
+// Fire invocations, followed by a RET so the trampoline can return normally.
+type probeSite struct {
+	pc unsafe.Pointer
+}
+
+// Probe is a location in Go code that can be "fired" with a set of arguments
+// such that extrenal tools (e.g. the `trace` tool from bcc) can attach to a
+// running process and inspect the values at runtime.
+type Probe struct {
+	name     string
+	argTypes []ProbeArgType
+	site     *probeSite
+}
+
+// probeImage is the memfd-backed mapping holding one provider's note section
+// and NOP landing pads.
+type probeImage struct {
+	fd  int
+	mem []byte
+}
+
+// Provider represents a named collection of probes
+type Provider struct {
+	mu     sync.Mutex
+	name   string
+	probes []*Probe
+	image  *probeImage
+}
+
+// NewProvider creates a probe provider with the supplied name. Provider
+// instances are in either a loaded or an unloaded state. When Provders are
+// unloaded (their initial state), probes can be created via AddProbe. Once the
+// Provider is loaded via the Load() function, the probe set should not be
+// changed. Probes can be cleared from the Provider instance by unloading it
+// via the Unload() function. Probe addition is not threadsafe steps must be
+// taken by clients of this library to ensure that at most one thread is
+// adding a Probe at a time.
+func NewProvider(name string) *Provider {
+	return &Provider{name: name}
+}
+
+// Name returns the name of the provider as a string
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// AddProbe creates a new Probe instance with the supplied name and assiciates
+// it with this Provider. The argTypes describe the arguments that are expected
+// to be supplied when Fire is called on this Probe. Note that a Bytes
+// argType occupies two of the six argument slots.
+func (p *Provider) AddProbe(name string, argTypes ...ProbeArgType) (*Probe, error) {
+	if n := slotCount(argTypes); n > maxArguments {
+		return nil, stapsdtError{msg: fmt.Sprintf(
+			"probe %q: %d argument slots exceeds the %d argument limit",
+			name, n, maxArguments)}
+	}
+	pr := &Probe{name: name, argTypes: append([]ProbeArgType(nil), argTypes...)}
+	p.probes = append(p.probes, pr)
+	return pr, nil
+}
+
+// slotCount returns the number of underlying argument slots argTypes expands
+// to; every type occupies one slot except Bytes, which occupies two.
+func slotCount(argTypes []ProbeArgType) int {
+	n := 0
+	for _, t := range argTypes {
+		if t == Bytes {
+			n += 2
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// Load transitions the provider from the unloaded state into the loaded state
+// which causes associated Probes to become active (i.e. calling Fire() on the
+// probe will actually work).
+func (p *Provider) Load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	obj, sites, err := buildProbeObject(p.name, p.probes)
+	if err != nil {
+		return err
+	}
+
+	img, err := mapProbeObject(obj)
+	if err != nil {
+		return err
+	}
+
+	for i, pr := range p.probes {
+		pr.site = &probeSite{pc: unsafe.Pointer(&img.mem[sites[i]])}
+	}
+	p.image = img
+	return nil
+}
+
+// Unload transitions this Provider from the loaded to the unloaded state.
+// Associated probes are detached and must be re-attached in order to function.
+func (p *Provider) Unload() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.image == nil {
+		p.probes = nil
+		return
+	}
+	for _, pr := range p.probes {
+		pr.site = nil
+	}
+	unmapProbeObject(p.image)
+	p.image = nil
+	p.probes = nil
+}
+
+// Dispose cleans up the Provider datastructures. The Provider instance is
+// useless after this method is invoked.
+func (p *Provider) Dispose() {
+	p.Unload()
+	p.probes = nil
+}
+
+// Name gets the name of this Probe as provided when it was originally created.
+func (p *Probe) Name() string {
+	return p.name
+}
+
+// Enabled returns true iff the provider associated with this Probe is in a
+// loaded state and the Probe is being monitored by an agent such as the bcc
+// trace tool.
+func (p *Probe) Enabled() bool {
+	site := p.site
+	if site == nil {
+		return false
+	}
+	// Same ~100x-cheaper trick the CGO backend uses: a tracer patches the
+	// landing pad's NOP byte in place, so we can tell whether we're being
+	// watched with a single memory read instead of a syscall.
+	return *(*uint8)(site.pc)&0x90 != 0x90
+}
+
+// Fire invokes the Probe with the provided arguments. The type and arity of
+// this invocation should match what was described by the ProbeArgType arguments
+// originally given to the Provider.AddProbe invocation that created this Probe.
+// Cheap to invoke if the probe is not enabled (see: Enabled())
+func (p *Probe) Fire(args ...interface{}) {
+	if !p.Enabled() || len(args) != len(p.argTypes) {
+		return
+	}
+	p.fireImpl(args...)
+}
+
+func (p *Probe) fireImpl(args ...interface{}) {
+	var ba [maxArguments]uintptr
+	slot := 0
+	for i := 0; i < len(args) && slot < len(ba); i++ {
+		switch ta := args[i].(type) {
+		case bool:
+			if ta {
+				ba[slot] = 1
+			}
+		case int8:
+			ba[slot] = uintptr(ta)
+		case uint8: // catches byte
+			ba[slot] = uintptr(ta)
+		case int16:
+			ba[slot] = uintptr(ta)
+		case uint16:
+			ba[slot] = uintptr(ta)
+		case int:
+			ba[slot] = uintptr(ta)
+		case uint:
+			ba[slot] = uintptr(ta)
+		case int32:
+			ba[slot] = uintptr(ta)
+		case uint32:
+			ba[slot] = uintptr(ta)
+		case int64:
+			ba[slot] = uintptr(ta)
+		case uint64:
+			ba[slot] = uintptr(ta)
+		case float32:
+			ba[slot] = uintptr(math.Float32bits(ta))
+		case float64:
+			ba[slot] = uintptr(math.Float64bits(ta))
+		case uintptr:
+			ba[slot] = ta
+		case unsafe.Pointer:
+			ba[slot] = uintptr(ta)
+		case string:
+			ba[slot] = uintptr(unsafe.Pointer(stringToCStr(ta)))
+		case error:
+			ba[slot] = uintptr(unsafe.Pointer(stringToCStr(ta.Error())))
+		case []byte:
+			var bptr uintptr
+			if len(ta) > 0 {
+				bptr = uintptr(unsafe.Pointer(&ta[0]))
+			}
+			ba[slot] = bptr
+			slot++
+			if slot >= len(ba) {
+				return
+			}
+			ba[slot] = uintptr(len(ta))
+		default:
+			return
+		}
+		slot++
+	}
+	callTrampoline(uintptr(p.site.pc), &ba, slot)
+}
+
+// argsPools holds one sync.Pool of *Args per possible probe arity (0 through
+// maxArguments), so FireFunc can reuse a scratch buffer sized for the probe
+// it's firing instead of allocating one per call.
+var argsPools [maxArguments + 1]sync.Pool
+
+func getArgs(arity int) *Args {
+	a, _ := argsPools[arity].Get().(*Args)
+	if a == nil {
+		a = &Args{arity: arity}
+	}
+	a.slot = 0
+	return a
+}
+
+func putArgs(a *Args) {
+	argsPools[a.arity].Put(a)
+}
+
+// Args is the scratch area Probe.FireFunc hands to its build function. Its
+// typed setters are called in argument order and write straight into a
+// buffer reused across calls (see argsPools), rather than allocating a new
+// []byte via stringToCStr for every string or error argument the way Fire
+// does.
+type Args struct {
+	arity int
+	slot  int
+	ba    [maxArguments]uintptr
+	cbufs [maxArguments][]byte // reusable NUL-terminated scratch, one per slot
+}
+
+// room reports whether n more slots can still be written, silently
+// protecting against a FireFunc closure that calls more setters than the
+// probe has argument slots for -- the same "ignore a mismatched call instead
+// of crashing" behavior Fire falls back to for a bad arg count, which
+// matters here because Args is only ever touched once a tracer is attached.
+func (a *Args) room(n int) bool {
+	return a.slot+n <= a.arity
+}
+
+// SetInt64 sets the next argument slot to v. Use it for any of the integer
+// ProbeArgTypes; the note's argument spec only distinguishes them by width,
+// not at Fire time. A call beyond the probe's declared arity is ignored.
+func (a *Args) SetInt64(v int64) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = uintptr(v)
+	a.slot++
+}
+
+// SetFloat64 sets the next argument slot to v, encoded via math.Float64bits
+// the same way Fire encodes a float64 argument. A call beyond the probe's
+// declared arity is ignored.
+func (a *Args) SetFloat64(v float64) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = uintptr(math.Float64bits(v))
+	a.slot++
+}
+
+// SetString sets the next argument slot to v, copying it into a reusable
+// NUL-terminated buffer instead of allocating a new one via stringToCStr. A
+// call beyond the probe's declared arity is ignored.
+func (a *Args) SetString(v string) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = uintptr(unsafe.Pointer(a.cstr(v)))
+	a.slot++
+}
+
+// SetError sets the next argument slot to err.Error(), using the same
+// reusable buffer as SetString. A call beyond the probe's declared arity is
+// ignored.
+func (a *Args) SetError(err error) {
+	if !a.room(1) {
+		return
+	}
+	a.ba[a.slot] = uintptr(unsafe.Pointer(a.cstr(err.Error())))
+	a.slot++
+}
+
+// SetBytes sets the next two argument slots to a (pointer, length) pair
+// describing b, matching the Bytes ProbeArgType. A call that would overflow
+// the probe's declared arity is ignored.
+func (a *Args) SetBytes(b []byte) {
+	if !a.room(2) {
+		return
+	}
+	var ptr uintptr
+	if len(b) > 0 {
+		ptr = uintptr(unsafe.Pointer(&b[0]))
+	}
+	a.ba[a.slot] = ptr
+	a.slot++
+	a.ba[a.slot] = uintptr(len(b))
+	a.slot++
+}
+
+// cstr copies s into this slot's reusable buffer, NUL-terminated, growing the
+// buffer if needed, and returns a pointer to it.
+func (a *Args) cstr(s string) *byte {
+	buf := a.cbufs[a.slot]
+	need := len(s) + 1
+	if cap(buf) < need {
+		buf = make([]byte, need)
+	} else {
+		buf = buf[:need]
+	}
+	copy(buf, s)
+	buf[len(s)] = 0
+	a.cbufs[a.slot] = buf
+	return &buf[0]
+}
+
+// FireFunc is like Fire, but build is only invoked when Enabled() returns
+// true, and the *Args it's given encodes arguments into a buffer reused
+// across calls instead of allocating a new string buffer for every string or
+// error argument. Prefer this over Fire on hot paths whose arguments are
+// expensive to compute or include strings.
+func (p *Probe) FireFunc(build func(*Args)) {
+	if !p.Enabled() {
+		return
+	}
+	a := getArgs(slotCount(p.argTypes))
+	defer putArgs(a)
+	build(a)
+	callTrampoline(uintptr(p.site.pc), &a.ba, a.slot)
+}
+
+// liveCStrings retains every buffer handed out by stringToCStr for the life
+// of the process. A tracer reads the buffer's address (stashed in ba as a
+// bare uintptr, which the garbage collector does not treat as a reference)
+// at an unpredictable later time, so the buffer itself must be kept
+// reachable through an ordinary pointer somewhere, or the GC is free to
+// collect it between encoding the argument and the tracer reading it.
+var (
+	liveCStringsMu sync.Mutex
+	liveCStrings   []*byte
+)
+
+// stringToCStr allocates a NUL-terminated copy of s that the trampoline can
+// hand to the tracer as a pointer argument. It intentionally leaks: the
+// buffer is recorded in liveCStrings so the GC never reclaims it, since it
+// cannot be freed as soon as Fire returns.
+func stringToCStr(s string) *byte {
+	b := make([]byte, len(s)+1)
+	copy(b, s)
+	ptr := &b[0]
+	liveCStringsMu.Lock()
+	liveCStrings = append(liveCStrings, ptr)
+	liveCStringsMu.Unlock()
+	return ptr
+}
+
+// callTrampoline is implemented in salp_purego_amd64.s. It loads up to
+// maxArguments values from args into the System V AMD64 integer argument
+// registers (rdi, rsi, rdx, rcx, r8, r9) and calls fn, which is expected to be
+// a probe's landing pad: a single NOP followed by a RET.
+func callTrampoline(fn uintptr, args *[maxArguments]uintptr, nargs int)
+
+// buildProbeObject renders a minimal ELF64 ET_DYN object containing one NOP
+// landing pad per probe (in .text) and a .note.stapsdt note per probe
+// describing it, anchored to a .stapsdt.base section the way libstapsdt's
+// output is. It returns the object bytes along with each probe's landing-pad
+// offset within those bytes (in the same order as probes).
+func buildProbeObject(provider string, probes []*Probe) ([]byte, []uint64, error) {
+	const padStride = 16 // bytes between landing pads; generous alignment
+
+	var text []byte
+	sites := make([]uint64, len(probes))
+	for i := range probes {
+		sites[i] = uint64(len(text))
+		text = append(text, 0x90, 0xC3) // NOP; RET
+		for len(text)%padStride != 0 {
+			text = append(text, 0x90)
+		}
+	}
+	if len(text) == 0 {
+		text = []byte{0x90, 0xC3}
+	}
+
+	const ehdrSize = 64
+	const shdrSize = 64
+	textOff := uint64(ehdrSize)
+	textAddr := textOff
+
+	baseOff := textOff + uint64(len(text))
+	baseAddr := baseOff
+	base := []byte{0}
+
+	var notes []byte
+	for i, pr := range probes {
+		spec, err := argSpecString(pr.argTypes)
+		if err != nil {
+			return nil, nil, err
+		}
+		notes = append(notes, buildStapsdtNote(textAddr+sites[i], baseAddr, provider, pr.name, spec)...)
+	}
+	notesOff := baseOff + uint64(len(base))
+
+	shstrtab, nameOff := buildShStrtab(".text", ".note.stapsdt", ".stapsdt.base", ".shstrtab")
+	shstrtabOff := notesOff + uint64(len(notes))
+
+	shoff := align8(shstrtabOff + uint64(len(shstrtab)))
+
+	buf := make([]byte, shoff+shdrSize*5)
+	writeElfHeader(buf, shoff, 5, 4)
+	copy(buf[textOff:], text)
+	copy(buf[baseOff:], base)
+	copy(buf[notesOff:], notes)
+	copy(buf[shstrtabOff:], shstrtab)
+
+	type section struct {
+		name, typ, flags, addr, off, size, align uint64
+	}
+	secs := []section{
+		{0, 0, 0, 0, 0, 0, 0}, // SHT_NULL
+		{nameOff[".text"], 1 /*PROGBITS*/, 0x6 /*ALLOC|EXECINSTR*/, textAddr, textOff, uint64(len(text)), 16},
+		{nameOff[".note.stapsdt"], 7 /*NOTE*/, 0x2 /*ALLOC*/, notesOff, notesOff, uint64(len(notes)), 4},
+		{nameOff[".stapsdt.base"], 1 /*PROGBITS*/, 0x2 /*ALLOC*/, baseAddr, baseOff, uint64(len(base)), 1},
+		{nameOff[".shstrtab"], 3 /*STRTAB*/, 0, 0, shstrtabOff, uint64(len(shstrtab)), 1},
+	}
+	for i, s := range secs {
+		off := int(shoff) + i*shdrSize
+		binary.LittleEndian.PutUint32(buf[off:], uint32(s.name))
+		binary.LittleEndian.PutUint32(buf[off+4:], uint32(s.typ))
+		binary.LittleEndian.PutUint64(buf[off+8:], s.flags)
+		binary.LittleEndian.PutUint64(buf[off+16:], s.addr)
+		binary.LittleEndian.PutUint64(buf[off+24:], s.off)
+		binary.LittleEndian.PutUint64(buf[off+32:], s.size)
+		binary.LittleEndian.PutUint64(buf[off+48:], s.align)
+	}
+	fileOffsets := make([]uint64, len(sites))
+	for i, s := range sites {
+		fileOffsets[i] = textOff + s
+	}
+	return buf, fileOffsets, nil
+}
+
+func writeElfHeader(buf []byte, shoff uint64, shnum, shstrndx uint16) {
+	copy(buf[0:], []byte{0x7f, 'E', 'L', 'F', 2 /*64-bit*/, 1 /*little endian*/, 1 /*version*/, 0})
+	binary.LittleEndian.PutUint16(buf[16:], 3)  // e_type = ET_DYN
+	binary.LittleEndian.PutUint16(buf[18:], 62) // e_machine = EM_X86_64
+	binary.LittleEndian.PutUint32(buf[20:], 1)  // e_version
+	binary.LittleEndian.PutUint16(buf[52:], 64) // e_ehsize
+	binary.LittleEndian.PutUint16(buf[58:], 64) // e_shentsize
+	binary.LittleEndian.PutUint64(buf[40:], shoff)
+	binary.LittleEndian.PutUint16(buf[60:], shnum)
+	binary.LittleEndian.PutUint16(buf[62:], shstrndx)
+}
+
+// buildStapsdtNote renders a single Elf64_Nhdr "stapsdt" note: pc, the
+// .stapsdt.base address, a zero semaphore (salp has no use for the
+// semaphore-toggle optimization), then the provider/probe/argspec strings.
+func buildStapsdtNote(pc, base uint64, provider, probe, argspec string) []byte {
+	name := []byte("stapsdt\x00")
+
+	var desc []byte
+	desc = binary.LittleEndian.AppendUint64(desc, pc)
+	desc = binary.LittleEndian.AppendUint64(desc, base)
+	desc = binary.LittleEndian.AppendUint64(desc, 0) // semaphore
+	desc = append(desc, []byte(provider+"\x00")...)
+	desc = append(desc, []byte(probe+"\x00")...)
+	desc = append(desc, []byte(argspec+"\x00")...)
+	for len(desc)%4 != 0 {
+		desc = append(desc, 0)
+	}
+
+	var note []byte
+	note = binary.LittleEndian.AppendUint32(note, uint32(len(name)))
+	note = binary.LittleEndian.AppendUint32(note, uint32(len(desc)))
+	note = binary.LittleEndian.AppendUint32(note, 3) // NT_STAPSDT
+	note = append(note, name...)
+	note = append(note, desc...)
+	return note
+}
+
+// argSpecString renders the argument-type spec string embedded in a note,
+// e.g. "-4@%rdi -8@%rsi", assigning registers in System V AMD64 order.
+func argSpecString(argTypes []ProbeArgType) (string, error) {
+	var parts []string
+	reg := 0
+	for _, t := range argTypes {
+		if reg >= maxArguments {
+			return "", fmt.Errorf("salp: too many argument slots for %d registers", maxArguments)
+		}
+		if t == Bytes {
+			ptrSpec, err := Ptr.argSpec(sysvArgRegs[reg])
+			if err != nil {
+				return "", err
+			}
+			lenSpec, err := Uint64.argSpec(sysvArgRegs[reg+1])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, ptrSpec, lenSpec)
+			reg += 2
+			continue
+		}
+		spec, err := t.argSpec(sysvArgRegs[reg])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, spec)
+		reg++
+	}
+	s := ""
+	for i, p := range parts {
+		if i > 0 {
+			s += " "
+		}
+		s += p
+	}
+	return s, nil
+}
+
+func align8(n uint64) uint64 {
+	return (n + 7) &^ 7
+}
+
+// buildShStrtab renders an ELF section-header string table containing names
+// (with the conventional leading NUL entry for the empty name) and returns
+// it alongside each name's offset within that table, so callers never have
+// to hand-compute (and risk miscounting) those offsets themselves.
+func buildShStrtab(names ...string) ([]byte, map[string]uint64) {
+	tab := []byte{0}
+	offs := make(map[string]uint64, len(names))
+	for _, name := range names {
+		offs[name] = uint64(len(tab))
+		tab = append(tab, name...)
+		tab = append(tab, 0)
+	}
+	return tab, offs
+}
+
+// mapProbeObject writes obj into an anonymous memfd and maps it
+// read+exec+shared, so that /proc/<pid>/maps (and map_files/<fd>) exposes it
+// to tracers the same way a dlopen'd libstapsdt .so would be.
+func mapProbeObject(obj []byte) (*probeImage, error) {
+	fd, err := memfdCreate("salp-probes")
+	if err != nil {
+		return nil, fmt.Errorf("salp: memfd_create: %w", err)
+	}
+	if err := syscall.Ftruncate(fd, int64(len(obj))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("salp: ftruncate: %w", err)
+	}
+	if _, err := syscall.Write(fd, obj); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("salp: write: %w", err)
+	}
+	mem, err := syscall.Mmap(fd, 0, len(obj),
+		syscall.PROT_READ|syscall.PROT_EXEC, syscall.MAP_SHARED)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("salp: mmap: %w", err)
+	}
+	return &probeImage{fd: fd, mem: mem}, nil
+}
+
+func unmapProbeObject(img *probeImage) {
+	syscall.Munmap(img.mem)
+	syscall.Close(img.fd)
+}
+
+// memfdCreate wraps the memfd_create(2) syscall, which the syscall package
+// does not expose directly.
+func memfdCreate(name string) (int, error) {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	const sysMemfdCreate = 319 // linux/amd64 __NR_memfd_create; not exported by the syscall package
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(p)), 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(fd), nil
+}
@@ -0,0 +1,70 @@
+//go:build salp_purego && linux
+
+package salp_test
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcshane/salp"
+)
+
+// TestBpftraceIntegration attaches a real bpftrace to this test process and
+// verifies it can see a value fired through the purego backend's
+// memfd-backed note, the same way a libstapsdt-built .so is traced, as a
+// parity check against the CGO backend; since bpftrace needs to run
+// privileged and isn't installed in most sandboxes, it skips instead of
+// failing when that's not possible.
+func TestBpftraceIntegration(t *testing.T) {
+	bpftrace, err := exec.LookPath("bpftrace")
+	if err != nil {
+		t.Skip("bpftrace not installed, skipping integration test")
+	}
+
+	pv := salp.NewProvider("salp-bpftrace-it")
+	defer salp.UnloadAndDispose(pv)
+	pr, err := pv.AddProbe("hit", salp.Int32)
+	require(t, err == nil, err)
+	require(t, pv.Load() == nil, "failed to load provider")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pid := strconv.Itoa(os.Getpid())
+	cmd := exec.CommandContext(ctx, bpftrace,
+		"-p", pid,
+		"-e", `usdt:hit { printf("saw %d\n", arg0); exit(); }`)
+	stdout, err := cmd.StdoutPipe()
+	require(t, err == nil, err)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("could not start bpftrace (likely missing privileges): %v", err)
+	}
+
+	// Give bpftrace a moment to attach the USDT probe before firing it.
+	time.Sleep(500 * time.Millisecond)
+	for i := 0; i < 20 && ctx.Err() == nil; i++ {
+		pr.Fire(int32(42))
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	saw := false
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), "saw 42") {
+			saw = true
+			break
+		}
+	}
+	cmd.Wait()
+
+	if ctx.Err() != nil {
+		t.Skip("bpftrace did not attach within the timeout (likely missing privileges/BTF in this environment)")
+	}
+	require(t, saw, "bpftrace did not observe the fired probe argument")
+}
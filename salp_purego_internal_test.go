@@ -0,0 +1,115 @@
+//go:build salp_purego && linux && amd64
+
+package salp
+
+import (
+	"bytes"
+	"debug/elf"
+	"runtime"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// TestBuildProbeObjectSectionNames parses the ELF object buildProbeObject
+// renders with debug/elf and asserts on the exact section names a tracer
+// would look for, catching string-table offset mistakes that a byte-level
+// diff against expected output would miss.
+func TestBuildProbeObjectSectionNames(t *testing.T) {
+	pr := &Probe{name: "bar", argTypes: []ProbeArgType{Int32}}
+	obj, _, err := buildProbeObject("foo", []*Probe{pr})
+	if err != nil {
+		t.Fatalf("buildProbeObject: %v", err)
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(obj))
+	if err != nil {
+		t.Fatalf("elf.NewFile: %v", err)
+	}
+	defer f.Close()
+
+	want := []string{"", ".text", ".note.stapsdt", ".stapsdt.base", ".shstrtab"}
+	if len(f.Sections) != len(want) {
+		t.Fatalf("got %d sections, want %d", len(f.Sections), len(want))
+	}
+	for i, name := range want {
+		if got := f.Sections[i].Name; got != name {
+			t.Errorf("section %d: got name %q, want %q", i, got, name)
+		}
+	}
+}
+
+// TestStringToCStrSurvivesGC guards against stringToCStr's buffer being
+// collected once only a bare uintptr (not a typed pointer) to it remains
+// live, which is exactly how fireImpl stores it in ba -- a real tracer reads
+// that address well after Fire returns, so a GC cycle landing in between
+// must not reclaim it. It checks this via liveCStrings directly rather than
+// round-tripping the address through a uintptr itself, since doing that
+// reconstruction in Go code (as opposed to a tracer reading raw memory from
+// outside the process) is itself the unsafe.Pointer misuse this fix avoids.
+func TestStringToCStrSurvivesGC(t *testing.T) {
+	ptr := stringToCStr("hello")
+
+	runtime.GC()
+	runtime.GC()
+
+	liveCStringsMu.Lock()
+	found := false
+	for _, p := range liveCStrings {
+		if p == ptr {
+			found = true
+			break
+		}
+	}
+	liveCStringsMu.Unlock()
+	if !found {
+		t.Fatal("stringToCStr's buffer is not retained in liveCStrings")
+	}
+
+	if got := unsafe.String(ptr, 5); got != "hello" {
+		t.Fatalf("buffer corrupted after GC: got %q, want %q", got, "hello")
+	}
+}
+
+// forceEnabled overwrites a probe's landing-pad byte as if a tracer had
+// attached to it, so that Enabled()-gated code (FireFunc, Fire) can be
+// benchmarked/tested on its "enabled" path without a real tracer. The
+// landing pad normally lives in a PROT_READ|PROT_EXEC mapping (see
+// mapProbeObject) -- exactly as a real tracer's own page permissions would
+// require it to use process_vm_writev or similar rather than a plain store
+// -- so this first grants itself write access to that page via mprotect.
+//
+// Unlike a real tracer, nothing here is waiting to trap on a breakpoint, and
+// FireFunc still genuinely executes this landing pad via callTrampoline; so
+// the replacement byte must both fail the NOP check in Enabled() and decode
+// to a harmless instruction on its own. A bare REX prefix (0x40, which sets
+// none of REX's W/R/X/B bits) followed by the existing RET byte decodes as a
+// plain RET -- the same "return immediately" behavior the NOP+RET pad had,
+// just not recognized as the untouched NOP byte.
+func forceEnabled(p *Probe) {
+	const pageSize = 4096
+	page := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(p.site.pc)&^(pageSize-1))), pageSize)
+	if err := syscall.Mprotect(page, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC); err != nil {
+		panic(err)
+	}
+	*(*uint8)(p.site.pc) = 0x40
+}
+
+// BenchmarkFireEnabledStrings measures FireFunc's lazy-encoding path with
+// string arguments against a probe forced into the "enabled" state, so the
+// cost of Args' reusable scratch buffer (instead of a fresh stringToCStr
+// allocation per call) is actually exercised rather than short-circuited by
+// Enabled() returning false.
+func BenchmarkFireEnabledStrings(b *testing.B) {
+	pv := NewProvider("foo")
+	pr := MustAddProbe(pv, "bar", String, String)
+	MustLoadProvider(pv)
+	forceEnabled(pr)
+
+	for i := 0; i < b.N; i++ {
+		pr.FireFunc(func(a *Args) {
+			a.SetString("hello")
+			a.SetString("world")
+		})
+	}
+}
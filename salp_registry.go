@@ -0,0 +1,345 @@
+package salp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Registry tracks every Provider (and its Probes) created through it, so that
+// a long-running process can expose what instrumentation it has and let an
+// operator toggle it at runtime -- the same role a dynamic log-level endpoint
+// plays for logging, but for USDT probes. Use NewControlHandler to serve a
+// Registry's contents over HTTP (or a Unix socket, by handing the returned
+// http.Handler to http.Serve with a "unix" net.Listener).
+//
+// A Registry's own bookkeeping is safe for concurrent use; it does not by
+// itself make the Providers/Probes it tracks safe to mutate concurrently --
+// see Register and Reload.
+type Registry struct {
+	mu        sync.Mutex
+	providers map[string]*registryEntry
+}
+
+// registryEntry is the bookkeeping a Registry keeps per Provider: the
+// Provider itself, the function that (re)defines its Probes, and the schema
+// recorded while that function last ran.
+type registryEntry struct {
+	mu       sync.Mutex // serializes Reload against itself; AddProbe isn't threadsafe
+	provider *Provider
+	build    func(*ProbeBuilder) error
+	probes   []*registeredProbe
+}
+
+type registeredProbe struct {
+	name     string
+	argTypes []ProbeArgType
+	probe    *Probe
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{providers: map[string]*registryEntry{}}
+}
+
+// ProbeBuilder is the scoped view of a Provider handed to the build function
+// passed to Register. Its AddProbe behaves exactly like Provider.AddProbe,
+// except that it also records the schema the Registry reports via Snapshot
+// and GET /probes.
+type ProbeBuilder struct {
+	provider *Provider
+	entry    *registryEntry
+}
+
+// AddProbe adds a probe to the Provider being built, recording it with the
+// Registry.
+func (b *ProbeBuilder) AddProbe(name string, argTypes ...ProbeArgType) (*Probe, error) {
+	pr, err := b.provider.AddProbe(name, argTypes...)
+	if err != nil {
+		return nil, err
+	}
+	b.entry.probes = append(b.entry.probes, &registeredProbe{
+		name:     name,
+		argTypes: append([]ProbeArgType(nil), argTypes...),
+		probe:    pr,
+	})
+	return pr, nil
+}
+
+// Register creates a Provider named name, tracks it with the Registry, and
+// calls build to define its probes and Load it. build is retained and called
+// again by Reload, so that a later call can pick up probes that didn't exist
+// the first time around (e.g. because they're driven by a config file).
+//
+// AddProbe is not threadsafe; as with Provider.AddProbe directly, callers
+// must ensure no other goroutine is defining probes on this Provider
+// concurrently with Register or a subsequent Reload.
+func (r *Registry) Register(name string, build func(*ProbeBuilder) error) (*Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[name]; exists {
+		return nil, fmt.Errorf("salp: provider %q is already registered", name)
+	}
+
+	p := NewProvider(name)
+	entry := &registryEntry{provider: p, build: build}
+	if err := build(&ProbeBuilder{provider: p, entry: entry}); err != nil {
+		return nil, err
+	}
+	if err := p.Load(); err != nil {
+		return nil, err
+	}
+	r.providers[name] = entry
+	return p, nil
+}
+
+// Reload unloads the named provider (clearing its probe set), re-runs its
+// build function, and loads it again, so that probes added or removed since
+// Register (or the last Reload) take effect without restarting the process.
+// Concurrent Reloads of the same provider are serialized against each other.
+func (r *Registry) Reload(name string) error {
+	r.mu.Lock()
+	entry, ok := r.providers[name]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("salp: no such provider %q", name)
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	entry.provider.Unload()
+	entry.probes = nil
+	if err := entry.build(&ProbeBuilder{provider: entry.provider, entry: entry}); err != nil {
+		return err
+	}
+	return entry.provider.Load()
+}
+
+// Fire is a diagnostic that fires the named probe with caller-supplied
+// arguments, type-coerced against the schema recorded for it. It exists so
+// an operator can check what a probe's Fire call would look like (and that a
+// tracer correctly picks it up) without instrumenting real code paths.
+func (r *Registry) Fire(providerName, probeName string, rawArgs []json.RawMessage) error {
+	rp, err := r.findProbe(providerName, probeName)
+	if err != nil {
+		return err
+	}
+	if len(rawArgs) != len(rp.argTypes) {
+		return fmt.Errorf("salp: probe %q/%q expects %d arguments, got %d",
+			providerName, probeName, len(rp.argTypes), len(rawArgs))
+	}
+	args := make([]interface{}, len(rawArgs))
+	for i, raw := range rawArgs {
+		v, err := convertArg(rp.argTypes[i], raw)
+		if err != nil {
+			return fmt.Errorf("salp: argument %d: %w", i, err)
+		}
+		args[i] = v
+	}
+	rp.probe.Fire(args...)
+	return nil
+}
+
+func (r *Registry) findProbe(providerName, probeName string) (*registeredProbe, error) {
+	r.mu.Lock()
+	entry, ok := r.providers[providerName]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("salp: no such provider %q", providerName)
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	for _, rp := range entry.probes {
+		if rp.name == probeName {
+			return rp, nil
+		}
+	}
+	return nil, fmt.Errorf("salp: provider %q has no probe %q", providerName, probeName)
+}
+
+// ProviderSnapshot is the typed, JSON-serializable view of a single
+// registered Provider returned by Registry.Snapshot.
+type ProviderSnapshot struct {
+	Name   string          `json:"name"`
+	Probes []ProbeSnapshot `json:"probes"`
+}
+
+// ProbeSnapshot is the typed, JSON-serializable view of a single Probe
+// tracked by a Registry.
+type ProbeSnapshot struct {
+	Name     string   `json:"name"`
+	ArgTypes []string `json:"argTypes"`
+	Enabled  bool     `json:"enabled"`
+}
+
+// Snapshot returns a point-in-time, typed view of every provider and probe
+// tracked by the Registry, suitable for programmatic use. GET /probes
+// reports the same data as JSON.
+func (r *Registry) Snapshot() []ProviderSnapshot {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.providers))
+	entries := make([]*registryEntry, 0, len(r.providers))
+	for name, entry := range r.providers {
+		names = append(names, name)
+		entries = append(entries, entry)
+	}
+	r.mu.Unlock()
+
+	snaps := make([]ProviderSnapshot, len(names))
+	for i, name := range names {
+		entry := entries[i]
+		entry.mu.Lock()
+		probes := make([]ProbeSnapshot, len(entry.probes))
+		for j, rp := range entry.probes {
+			argTypes := make([]string, len(rp.argTypes))
+			for k, t := range rp.argTypes {
+				argTypes[k] = t.String()
+			}
+			probes[j] = ProbeSnapshot{
+				Name:     rp.name,
+				ArgTypes: argTypes,
+				Enabled:  rp.probe.Enabled(),
+			}
+		}
+		entry.mu.Unlock()
+		snaps[i] = ProviderSnapshot{Name: name, Probes: probes}
+	}
+	return snaps
+}
+
+// NewControlHandler returns an http.Handler exposing reg for runtime
+// discovery and control:
+//
+//	GET  /probes                                JSON dump of reg.Snapshot()
+//	POST /providers/{name}/reload                reg.Reload(name)
+//	POST /probes/{provider}/{probe}/fire          reg.Fire with a JSON array body
+//
+// The handler has no opinion on transport: serve it over TCP with
+// http.ListenAndServe, or over a Unix socket with http.Serve and a
+// net.Listen("unix", path) listener.
+func NewControlHandler(reg *Registry) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/probes", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, reg.Snapshot())
+	})
+
+	mux.HandleFunc("/providers/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/providers/"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] != "reload" {
+			http.NotFound(w, req)
+			return
+		}
+		if err := reg.Reload(parts[0]); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("/probes/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/probes/"), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "fire" {
+			http.NotFound(w, req)
+			return
+		}
+		var rawArgs []json.RawMessage
+		if err := json.NewDecoder(req.Body).Decode(&rawArgs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := reg.Fire(parts[0], parts[1], rawArgs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// convertArg decodes a JSON-encoded probe argument into the Go value
+// Probe.Fire expects for t, using the JSON token's own shape (string, bool,
+// or number) together with t to pick the right concrete numeric type.
+func convertArg(t ProbeArgType, raw json.RawMessage) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	switch vv := v.(type) {
+	case string:
+		if t == Bytes {
+			return []byte(vv), nil
+		}
+		return vv, nil
+	case bool:
+		return vv, nil
+	case float64:
+		// Float32 is deliberately not its own case label here: in the
+		// default CGO backend it aliases Uint32 (libstapsdt has no float arg
+		// type), so a separate "case Float32" would be a duplicate-case
+		// compile error there. isFloat32ArgType is backend-specific instead:
+		// the purego backend, where Float32 is a distinct value, still
+		// converts to a real float32 (and so gets correctly
+		// math.Float32bits-encoded by fireImpl); the CGO backend can't tell
+		// Float32 apart from Uint32 and falls through to the Uint32 case.
+		if isFloat32ArgType(t) {
+			return float32(vv), nil
+		}
+		switch t {
+		case Int8:
+			return int8(vv), nil
+		case Uint8:
+			return uint8(vv), nil
+		case Int16:
+			return int16(vv), nil
+		case Uint16:
+			return uint16(vv), nil
+		case Int32:
+			return int32(vv), nil
+		case Uint32:
+			return uint32(vv), nil
+		case Int64:
+			return int64(vv), nil
+		case Ptr:
+			return uintptr(vv), nil
+		default:
+			// Uint64 only gets here on backends where it is distinct from
+			// Ptr. Float64 (and String, given a number) is encoded as a
+			// float64 argument (math.Float64bits), the same as any other
+			// float64 Fire argument. In the CGO backend Uint64, Float64,
+			// String and Ptr are one value and all take the Ptr case above,
+			// so a Float64 argument is converted to an integer there.
+			if t == Uint64 {
+				return uint64(vv), nil
+			}
+			return vv, nil
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode JSON value %s as a %v probe argument", raw, t)
+	}
+}
@@ -1,6 +1,10 @@
 package salp_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
@@ -19,6 +23,24 @@ func TestProbeWithTooManyArgs(t *testing.T) {
 	_, err = pv.AddProbe("baz", salp.Int8, salp.Int8,
 		salp.Int8, salp.Int8, salp.Int8, salp.Int8, salp.Int8)
 	require(t, err != nil, "expected error defining probe with 7 args")
+
+	// Bytes occupies two argument slots, so three of them fill all six
+	// slots and the extra Int8 exceeds the limit.
+	_, err = pv.AddProbe("quux", salp.Bytes, salp.Bytes, salp.Bytes, salp.Int8)
+	require(t, err != nil, "expected error defining probe with 7 slots")
+}
+
+func TestFireFloatPtrAndBytesArgs(t *testing.T) {
+	pv := salp.NewProvider("foo")
+	defer salp.UnloadAndDispose(pv)
+	pr, err := pv.AddProbe("bar", salp.Float64, salp.Bytes, salp.Ptr)
+
+	require(t, err == nil, err)
+
+	err = pv.Load()
+	require(t, err == nil, err)
+
+	pr.Fire(3.14, []byte("hello"), uintptr(42))
 }
 
 func TestDryFireAProbe(t *testing.T) {
@@ -41,6 +63,39 @@ func TestDryFireAProbe(t *testing.T) {
 	pr.Fire("bar", 3)
 }
 
+func TestRegistrySnapshotReloadAndFire(t *testing.T) {
+	reg := salp.NewRegistry()
+	_, err := reg.Register("foo", func(b *salp.ProbeBuilder) error {
+		_, err := b.AddProbe("bar", salp.Int32, salp.String)
+		return err
+	})
+	require(t, err == nil, err)
+
+	snap := reg.Snapshot()
+	require(t, len(snap) == 1 && snap[0].Name == "foo", snap)
+	require(t, len(snap[0].Probes) == 1 && snap[0].Probes[0].Name == "bar", snap)
+	require(t, !snap[0].Probes[0].Enabled, "expected untraced probe to be disabled")
+
+	require(t, reg.Reload("foo") == nil, "unexpected error reloading provider")
+
+	h := salp.NewControlHandler(reg)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/probes")
+	require(t, err == nil, err)
+	defer resp.Body.Close()
+	var snaps []salp.ProviderSnapshot
+	require(t, json.NewDecoder(resp.Body).Decode(&snaps) == nil, "bad /probes response")
+	require(t, len(snaps) == 1 && len(snaps[0].Probes) == 1, snaps)
+
+	body, _ := json.Marshal([]interface{}{3, "hi"})
+	resp, err = http.Post(srv.URL+"/probes/foo/bar/fire", "application/json", bytes.NewReader(body))
+	require(t, err == nil, err)
+	resp.Body.Close()
+	require(t, resp.StatusCode == http.StatusNoContent, resp.Status)
+}
+
 func TestProviderName(t *testing.T) {
 	pv := salp.NewProvider("foo")
 	require(t, pv.Name() == "foo")